@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// startAdminServer serves the local operator control plane described in
+// es-node-keeper's docs: node and cluster status, manual restarts, and
+// pause/resume of automatic restarts.
+func startAdminServer(addr string, engine *Engine) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nodes", handleNodes(engine))
+	mux.HandleFunc("/nodes/", handleNodeRestart(engine))
+	mux.HandleFunc("/cluster", handleCluster(engine))
+	mux.HandleFunc("/pause", handlePause(engine))
+	mux.HandleFunc("/resume", handleResume(engine))
+
+	slog.Info("Starting admin server", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("Admin server stopped", "error", err)
+	}
+}
+
+func handleNodes(engine *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, engine.NodesSnapshot())
+	}
+}
+
+func handleCluster(engine *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, engine.ClusterSnapshot())
+	}
+}
+
+// handleNodeRestart serves POST /nodes/{service}/restart.
+func handleNodeRestart(engine *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/restart") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		service := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/nodes/"), "/restart")
+		if service == "" {
+			http.Error(w, "missing service name", http.StatusBadRequest)
+			return
+		}
+
+		force := r.URL.Query().Get("force") == "true"
+		if err := engine.ForceRestart(service, force); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		writeJSON(w, map[string]string{"service": service, "status": "restarted"})
+	}
+}
+
+func handlePause(engine *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		engine.Pause()
+		writeJSON(w, map[string]string{"status": "paused"})
+	}
+}
+
+func handleResume(engine *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		engine.Resume()
+		writeJSON(w, map[string]string{"status": "resumed"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Cannot encode admin response", "error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// endpointCooldown is how long a failing endpoint is demoted for before it's
+// eligible to be tried again.
+const endpointCooldown = 60 * time.Second
+
+type endpoint struct {
+	url       string
+	demotedAt time.Time
+}
+
+// endpointPool tracks a set of Elasticsearch endpoints and provides failover
+// across them. A failing endpoint is demoted for a cooldown period, while
+// the endpoint that last served a request successfully is promoted to the
+// head of the list so it's tried first on the next tick. It's safe for
+// concurrent use so other subsystems (metrics, admin API) can share it with
+// the node keeper loop.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []endpoint
+	cooldown  time.Duration
+}
+
+func newEndpointPool(urls []string, cooldown time.Duration) *endpointPool {
+	endpoints := make([]endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = endpoint{url: url}
+	}
+
+	return &endpointPool{
+		endpoints: endpoints,
+		cooldown:  cooldown,
+	}
+}
+
+// ordered returns endpoint URLs in the order they should be tried: endpoints
+// outside their cooldown window first, in current pool order, followed by
+// still-cooling-down endpoints as a last resort.
+func (p *endpointPool) ordered() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var live, cooling []string
+	now := time.Now()
+	for _, e := range p.endpoints {
+		if e.demotedAt.IsZero() || now.Sub(e.demotedAt) >= p.cooldown {
+			live = append(live, e.url)
+		} else {
+			cooling = append(cooling, e.url)
+		}
+	}
+
+	return append(live, cooling...)
+}
+
+// promote moves url to the head of the pool and clears its demotion, so it's
+// tried first on the next tick.
+func (p *endpointPool) promote(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, e := range p.endpoints {
+		if e.url == url {
+			promoted := e
+			promoted.demotedAt = time.Time{}
+			p.endpoints = append(p.endpoints[:i:i], p.endpoints[i+1:]...)
+			p.endpoints = append([]endpoint{promoted}, p.endpoints...)
+			return
+		}
+	}
+}
+
+// demote marks url as having just failed so it's deprioritized for the
+// cooldown period.
+func (p *endpointPool) demote(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, e := range p.endpoints {
+		if e.url == url {
+			p.endpoints[i].demotedAt = time.Now()
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndpointPoolOrderedDefaultsToConfiguredOrder(t *testing.T) {
+	pool := newEndpointPool([]string{"http://a", "http://b", "http://c"}, endpointCooldown)
+
+	got := pool.ordered()
+	want := []string{"http://a", "http://b", "http://c"}
+	if !equalSlices(got, want) {
+		t.Fatalf("ordered() = %v, want %v", got, want)
+	}
+}
+
+func TestEndpointPoolPromoteMovesEndpointToHead(t *testing.T) {
+	pool := newEndpointPool([]string{"http://a", "http://b", "http://c"}, endpointCooldown)
+
+	pool.promote("http://c")
+
+	got := pool.ordered()
+	want := []string{"http://c", "http://a", "http://b"}
+	if !equalSlices(got, want) {
+		t.Fatalf("ordered() = %v, want %v", got, want)
+	}
+}
+
+func TestEndpointPoolDemoteDeprioritizesUntilCooldownExpires(t *testing.T) {
+	pool := newEndpointPool([]string{"http://a", "http://b"}, 50*time.Millisecond)
+
+	pool.demote("http://a")
+
+	got := pool.ordered()
+	want := []string{"http://b", "http://a"}
+	if !equalSlices(got, want) {
+		t.Fatalf("ordered() right after demote = %v, want %v", got, want)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	got = pool.ordered()
+	want = []string{"http://a", "http://b"}
+	if !equalSlices(got, want) {
+		t.Fatalf("ordered() after cooldown = %v, want %v", got, want)
+	}
+}
+
+func TestEndpointPoolPromoteClearsDemotion(t *testing.T) {
+	pool := newEndpointPool([]string{"http://a", "http://b"}, time.Hour)
+
+	pool.demote("http://a")
+	pool.promote("http://a")
+
+	got := pool.ordered()
+	want := []string{"http://a", "http://b"}
+	if !equalSlices(got, want) {
+		t.Fatalf("ordered() = %v, want %v", got, want)
+	}
+}
+
+func equalSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
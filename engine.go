@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trackedNode is the keeper's in-memory state for one configured local
+// node: which ES instance name it should show up as, which ServiceManager
+// backend restarts it, whether it was active on the last poll, and when it
+// was last restarted.
+type trackedNode struct {
+	instance             string
+	manager              ServiceManager
+	lastObservedActive   bool
+	lastRestartTimestamp int
+
+	// restartMu serializes the whole decide-then-restart sequence for this
+	// service, so the background tick loop and an admin-API ForceRestart
+	// can't both pass their gating checks and call performRestart for the
+	// same service concurrently. It's distinct from Engine.mu, which only
+	// ever protects individual field reads/writes.
+	restartMu sync.Mutex
+}
+
+// Engine owns the node keeper's state - the endpoint pool, the configured
+// nodes and the last observed cluster conditions - behind a mutex, so the
+// admin HTTP handlers can read and mutate it safely alongside the polling
+// loop.
+type Engine struct {
+	mu    sync.Mutex
+	pool  *endpointPool
+	nodes map[string]*trackedNode
+
+	paused                   bool
+	clusterStatus            string
+	clusterRoutingAllocation string
+	lastPollTime             time.Time
+	lastPollError            string
+}
+
+func NewEngine(pool *endpointPool, nodes map[string]*trackedNode) *Engine {
+	return &Engine{pool: pool, nodes: nodes}
+}
+
+// buildNodeStates resolves the ServiceManager backend configured for each
+// local node and returns the keeper's initial state for them.
+func buildNodeStates(localNodes LocalNodes) (map[string]*trackedNode, error) {
+	nodes := make(map[string]*trackedNode)
+	for _, localNode := range localNodes.Nodes {
+		manager, err := newServiceManager(localNode.Manager, localNode.Namespace, localNode.RestartCmd, localNode.StatusCmd)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %v", localNode.Service, err)
+		}
+
+		nodes[localNode.Service] = &trackedNode{
+			instance: localNode.Instance,
+			manager:  manager,
+		}
+	}
+
+	return nodes, nil
+}
+
+func sleepLoop() {
+	time.Sleep(time.Second * time.Duration(interval))
+}
+
+// Run polls the cluster forever, restarting local nodes that have dropped
+// out of it, until the process exits.
+func (e *Engine) Run() {
+	for {
+		e.tick()
+		sleepLoop()
+	}
+}
+
+func (e *Engine) tick() {
+	activeNodes, err := getActiveNodes(e.pool)
+
+	e.mu.Lock()
+	e.lastPollTime = time.Now()
+	if err != nil {
+		e.lastPollError = err.Error()
+	} else {
+		e.lastPollError = ""
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		slog.Warn("Cannot get active nodes from cluster")
+		return
+	}
+	recordSuccessfulPoll()
+	metricActiveNodes.Set(float64(len(activeNodes)))
+
+	e.pollClusterConditions()
+
+	e.mu.Lock()
+	metricLocalNodesTotal.Set(float64(len(e.nodes)))
+	var invalidServices []string
+	for service, node := range e.nodes {
+		_, active := activeNodes[node.instance]
+		node.lastObservedActive = active
+		if !active {
+			invalidServices = append(invalidServices, service)
+		}
+	}
+	paused := e.paused
+	e.mu.Unlock()
+
+	metricLocalNodesInvalid.Set(float64(len(invalidServices)))
+
+	if len(invalidServices) == 0 {
+		slog.Debug("All local nodes are active members of the cluster")
+		return
+	}
+
+	if paused {
+		slog.Debug("Restart actions are paused, skipping invalid nodes", "nodes", invalidServices)
+		return
+	}
+
+	for _, service := range invalidServices {
+		e.maybeRestart(service)
+	}
+}
+
+// pollClusterConditions polls the cluster's health status and routing
+// allocation and records them as metrics and engine state. It runs every
+// tick regardless of whether any local node is currently invalid, so
+// esnk_cluster_status and esnk_cluster_routing_allocation always carry a
+// recent sample instead of only being populated along the restart path.
+func (e *Engine) pollClusterConditions() {
+	clusterStatus, err := getClusterStatus(e.pool)
+	if err != nil {
+		slog.Warn("Cannot get cluster status")
+		return
+	}
+	setEnumGauge(metricClusterStatus, knownClusterStatuses, strings.ToLower(clusterStatus))
+
+	clusterRoutingAllocation, err := getClusterRoutingAllocation(e.pool)
+	if err != nil {
+		slog.Warn("Cannot get cluster routing allocation")
+		return
+	}
+	setEnumGauge(metricClusterRoutingAllocation, knownRoutingAllocations, strings.ToLower(clusterRoutingAllocation))
+
+	e.mu.Lock()
+	e.clusterStatus = clusterStatus
+	e.clusterRoutingAllocation = clusterRoutingAllocation
+	e.mu.Unlock()
+}
+
+// maybeRestart restarts service if it's been out of the restart-exclusion
+// window and the cluster is in a condition where it's safe to do so.
+func (e *Engine) maybeRestart(service string) {
+	e.mu.Lock()
+	node := e.nodes[service]
+	e.mu.Unlock()
+
+	node.restartMu.Lock()
+	defer node.restartMu.Unlock()
+
+	lastActiveEnter, err := node.manager.LastActiveEnter(service)
+	if err == nil {
+		e.mu.Lock()
+		node.lastRestartTimestamp = int(lastActiveEnter.Unix())
+		e.mu.Unlock()
+	} else {
+		slog.Warn("Cannot get service running time", "service", service, "error", err)
+	}
+
+	e.mu.Lock()
+	lastRestartTimestamp := node.lastRestartTimestamp
+	e.mu.Unlock()
+
+	if int(time.Now().Unix())-lastRestartTimestamp <= *restartExclusionPeriod {
+		slog.Debug("Cannot restart service because the minimum time between restarts has not been met", "service", service)
+		return
+	}
+
+	e.mu.Lock()
+	clusterStatus := e.clusterStatus
+	clusterRoutingAllocation := e.clusterRoutingAllocation
+	e.mu.Unlock()
+
+	if clusterRoutingAllocation == "" {
+		slog.Warn("Cluster routing allocation is empty")
+		return
+	}
+
+	if strings.ToLower(clusterStatus) == "red" || strings.ToLower(clusterRoutingAllocation) != "all" {
+		slog.Debug("Cannot restart service due to cluster conditions", "service", service)
+		return
+	}
+
+	slog.Info("Local node is not an active member of the cluster, restarting service",
+		"node", node.instance,
+		"service", service,
+	)
+	if err := e.performRestart(service, node); err != nil {
+		slog.Error("Cannot restart service", "service", service, "error", err)
+	}
+}
+
+// performRestart runs the configured ServiceManager's restart, honoring
+// --dry-run, and records the outcome in metrics and node state.
+func (e *Engine) performRestart(service string, node *trackedNode) error {
+	if *dryRun {
+		slog.Info("Dry run, skipping")
+		metricServiceRestartsTotal.WithLabelValues(service, "dry_run").Inc()
+		return nil
+	}
+
+	if err := node.manager.Restart(service); err != nil {
+		metricServiceRestartsTotal.WithLabelValues(service, "error").Inc()
+		return err
+	}
+
+	now := int(time.Now().Unix())
+	e.mu.Lock()
+	node.lastRestartTimestamp = now
+	e.mu.Unlock()
+
+	slog.Info("Service restarted", "service", service)
+	metricServiceRestartsTotal.WithLabelValues(service, "success").Inc()
+	metricLastRestartTimestamp.WithLabelValues(service).Set(float64(now))
+
+	return nil
+}
+
+// ForceRestart is the admin-API entry point for a manual restart. With
+// force set, it bypasses the restart-exclusion-period, the pause flag and
+// cluster gating.
+func (e *Engine) ForceRestart(service string, force bool) error {
+	e.mu.Lock()
+	node, ok := e.nodes[service]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown service %q", service)
+	}
+
+	node.restartMu.Lock()
+	defer node.restartMu.Unlock()
+
+	e.mu.Lock()
+	paused := e.paused
+	clusterStatus := e.clusterStatus
+	clusterRoutingAllocation := e.clusterRoutingAllocation
+	lastRestartTimestamp := node.lastRestartTimestamp
+	e.mu.Unlock()
+
+	if !force {
+		if paused {
+			return fmt.Errorf("restart actions are paused, retry with ?force=true")
+		}
+		if int(time.Now().Unix())-lastRestartTimestamp <= *restartExclusionPeriod {
+			return fmt.Errorf("restart-exclusion-period has not elapsed, retry with ?force=true")
+		}
+		if strings.ToLower(clusterStatus) == "red" || strings.ToLower(clusterRoutingAllocation) != "all" {
+			return fmt.Errorf("cluster conditions don't allow a restart, retry with ?force=true")
+		}
+	}
+
+	return e.performRestart(service, node)
+}
+
+// Pause suspends automatic and ungated manual restart actions, e.g. during
+// planned maintenance.
+func (e *Engine) Pause() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paused = true
+}
+
+// Resume reverses Pause.
+func (e *Engine) Resume() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paused = false
+}
+
+// NodeStatus is the admin API's view of one configured local node.
+type NodeStatus struct {
+	Service           string `json:"service"`
+	Instance          string `json:"instance"`
+	Active            bool   `json:"active"`
+	LastRestart       int64  `json:"last_restart_timestamp"`
+	CooldownRemaining int64  `json:"cooldown_remaining_seconds"`
+}
+
+// NodesSnapshot returns the current status of every configured local node.
+func (e *Engine) NodesSnapshot() []NodeStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now().Unix()
+	result := make([]NodeStatus, 0, len(e.nodes))
+	for service, node := range e.nodes {
+		remaining := int64(*restartExclusionPeriod) - (now - int64(node.lastRestartTimestamp))
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		result = append(result, NodeStatus{
+			Service:           service,
+			Instance:          node.instance,
+			Active:            node.lastObservedActive,
+			LastRestart:       int64(node.lastRestartTimestamp),
+			CooldownRemaining: remaining,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Service < result[j].Service })
+
+	return result
+}
+
+// ClusterSnapshot is the admin API's view of the last known cluster state.
+type ClusterSnapshot struct {
+	Status            string `json:"status"`
+	RoutingAllocation string `json:"routing_allocation"`
+	Endpoint          string `json:"endpoint"`
+	LastPollTimestamp int64  `json:"last_poll_timestamp"`
+	LastPollError     string `json:"last_poll_error,omitempty"`
+	Paused            bool   `json:"paused"`
+}
+
+// ClusterSnapshot returns the last known cluster status and routing
+// allocation, which endpoint is currently in use, and when the last poll
+// happened.
+func (e *Engine) ClusterSnapshot() ClusterSnapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var endpoint string
+	if endpoints := e.pool.ordered(); len(endpoints) > 0 {
+		endpoint = endpoints[0]
+	}
+
+	var lastPollTimestamp int64
+	if !e.lastPollTime.IsZero() {
+		lastPollTimestamp = e.lastPollTime.Unix()
+	}
+
+	return ClusterSnapshot{
+		Status:            e.clusterStatus,
+		RoutingAllocation: e.clusterRoutingAllocation,
+		Endpoint:          endpoint,
+		LastPollTimestamp: lastPollTimestamp,
+		LastPollError:     e.lastPollError,
+		Paused:            e.paused,
+	}
+}
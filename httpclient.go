@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+var (
+	username           = kingpin.Flag("username", "basic auth username for Elasticsearch").Envar("ESNK_USERNAME").String()
+	password           = kingpin.Flag("password", "basic auth password for Elasticsearch").Envar("ESNK_PASSWORD").String()
+	passwordFile       = kingpin.Flag("password-file", "file containing the basic auth password, instead of --password").ExistingFile()
+	apiKey             = kingpin.Flag("api-key", "Elasticsearch API key").Envar("ESNK_API_KEY").String()
+	apiKeyFile         = kingpin.Flag("api-key-file", "file containing the Elasticsearch API key, instead of --api-key").ExistingFile()
+	caFile             = kingpin.Flag("ca-file", "CA bundle used to verify the Elasticsearch server certificate").ExistingFile()
+	certFile           = kingpin.Flag("cert-file", "client certificate for mTLS").ExistingFile()
+	keyFile            = kingpin.Flag("key-file", "client private key for mTLS").ExistingFile()
+	insecureSkipVerify = kingpin.Flag("insecure-skip-verify", "skip Elasticsearch server certificate verification").Bool()
+)
+
+// httpClient is built once at startup from the auth/TLS flags and reused
+// across all Elasticsearch requests, instead of the old per-call
+// client := &http.Client{} pattern.
+var httpClient *http.Client
+
+// buildHTTPClient assembles the shared *http.Client for talking to
+// Elasticsearch, wiring up the CA bundle and client certificate (mTLS) if
+// configured. Must be called after kingpin.Parse().
+func buildHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: *insecureSkipVerify}
+
+	if *caFile != "" {
+		caCert, err := ioutil.ReadFile(*caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("cannot parse CA file %s", *caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if *certFile != "" || *keyFile != "" {
+		if *certFile == "" || *keyFile == "" {
+			return nil, fmt.Errorf("--cert-file and --key-file must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// No blanket Timeout here: each request's deadline comes from the
+	// context doHTTPGet attaches (attemptTimeout), which is deliberately
+	// shorter than *timeout so httpGetURLWithRetry's backoff still has
+	// budget left for further attempts after one hangs.
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// applyAuth sets the request's auth header from the configured credentials,
+// preferring an API key over basic auth when both are set.
+func applyAuth(req *http.Request) error {
+	if *apiKey != "" || *apiKeyFile != "" {
+		key, err := resolveSecret(*apiKey, *apiKeyFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "ApiKey "+key)
+		return nil
+	}
+
+	if *username != "" {
+		pass, err := resolveSecret(*password, *passwordFile)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(*username, pass)
+	}
+
+	return nil
+}
+
+// resolveSecret reads a secret from file if set, so it doesn't need to be
+// passed on the process command line; otherwise it falls back to value
+// (itself typically sourced from an env var).
+func resolveSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file %s: %v", file, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
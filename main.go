@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,7 +10,6 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"regexp"
 	"strings"
 	"time"
 
@@ -18,18 +18,19 @@ import (
 )
 
 const (
-	ver               string = "0.19"
-	interval          int    = 30
-	systemdDateLayout string = "Mon 2006-01-02 15:04:05 MST"
+	ver      string = "0.19"
+	interval int    = 30
 )
 
 var (
-	esUrl                  = kingpin.Flag("url", "elasticsearch URL").Default("http://localhost:9200").Short('u').String()
+	esUrls                 = kingpin.Flag("url", "elasticsearch URL, comma-separated or repeated for a multi-node seed list").Default("http://localhost:9200").Short('u').Strings()
 	timeout                = kingpin.Flag("timeout", "timeout for HTTP requests in seconds").Default("10").Short('t').Int()
 	config                 = kingpin.Flag("config", "config file path").Default("/etc/es-node-keeper.yaml").Short('c').String()
 	restartExclusionPeriod = kingpin.Flag("restart-exclusion-period", "minimal time in seconds between service restarts").Default("600").Int()
 	dryRun                 = kingpin.Flag("dry-run", "dry run").Short('n').Bool()
 	verbose                = kingpin.Flag("verbose", "Verbose mode.").Short('v').Bool()
+	metricsAddr            = kingpin.Flag("metrics-addr", "address to expose Prometheus metrics, /healthz and /readyz on, e.g. :9114 (disabled if empty)").Default("").String()
+	adminAddr              = kingpin.Flag("admin-addr", "address to expose the local admin API on, e.g. :9115 (disabled if empty)").Default("").String()
 )
 
 type Node struct {
@@ -38,8 +39,12 @@ type Node struct {
 
 type LocalNodes struct {
 	Nodes []struct {
-		Instance string `yaml:"instance"`
-		Service  string `yaml:"service"`
+		Instance   string `yaml:"instance"`
+		Service    string `yaml:"service"`
+		Manager    string `yaml:"manager"`
+		Namespace  string `yaml:"namespace"`
+		RestartCmd string `yaml:"restart_cmd"`
+		StatusCmd  string `yaml:"status_cmd"`
 	} `yaml:"nodes"`
 }
 
@@ -59,11 +64,48 @@ type ClusterSettings struct {
 	} `json:"transient"`
 }
 
-func httpGet(url string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Second * time.Duration(*timeout),
+// httpGet issues path against each endpoint in pool, in failover order,
+// until one succeeds. Each endpoint is retried with backoff for transient
+// failures before httpGet moves on to the next one. The endpoint that
+// served the response is promoted to the head of the pool for the next
+// call; endpoints that ultimately fail are demoted for a cooldown period.
+func httpGet(pool *endpointPool, path string) (string, error) {
+	var lastErr error
+	for _, url := range pool.ordered() {
+		body, err := httpGetURLWithRetry(url + path)
+		if err != nil {
+			slog.Warn("Request failed, trying next endpoint", "url", url, "error", err)
+			pool.demote(url)
+			lastErr = err
+			continue
+		}
+
+		pool.promote(url)
+		return body, nil
+	}
+
+	return "", fmt.Errorf("all endpoints failed: %v", lastErr)
+}
+
+func httpGetURL(url string) (string, error) {
+	start := time.Now()
+	body, err := doHTTPGet(url)
+	metricHTTPRequestDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metricHTTPRequestsTotal.WithLabelValues("error").Inc()
+		return "", err
 	}
-	req, err := http.NewRequest("GET", url, nil)
+
+	metricHTTPRequestsTotal.WithLabelValues("success").Inc()
+	return body, nil
+}
+
+func doHTTPGet(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), attemptTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -72,7 +114,11 @@ func httpGet(url string) (string, error) {
 	// It prevents re-use of TCP connections between requests to the same hosts
 	req.Close = true
 
-	resp, err := client.Do(req)
+	if err := applyAuth(req); err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -83,6 +129,10 @@ func httpGet(url string) (string, error) {
 		return "", err
 	}
 
+	if resp.StatusCode >= 300 {
+		return "", &httpStatusError{statusCode: resp.StatusCode}
+	}
+
 	return string(body), nil
 }
 
@@ -131,10 +181,8 @@ func parseConfig(file string) (LocalNodes, error) {
 	return nodes, nil
 }
 
-func getActiveNodes(esUrl string) (map[string]struct{}, error) {
-	url := esUrl + "/_cat/nodes?h=name&format=json"
-
-	esData, err := httpGet(url)
+func getActiveNodes(pool *endpointPool) (map[string]struct{}, error) {
+	esData, err := httpGet(pool, "/_cat/nodes?h=name&format=json")
 	if err != nil {
 		return map[string]struct{}{}, err
 	}
@@ -152,10 +200,8 @@ func getActiveNodes(esUrl string) (map[string]struct{}, error) {
 	return result, nil
 }
 
-func getClusterStatus(esUrl string) (string, error) {
-	url := esUrl + "/_cluster/health"
-
-	esData, err := httpGet(url)
+func getClusterStatus(pool *endpointPool) (string, error) {
+	esData, err := httpGet(pool, "/_cluster/health")
 	if err != nil {
 		return "", err
 	}
@@ -168,10 +214,8 @@ func getClusterStatus(esUrl string) (string, error) {
 	return clusterStatus.Status, nil
 }
 
-func getClusterRoutingAllocation(esUrl string) (string, error) {
-	url := esUrl + "/_cluster/settings"
-
-	esData, err := httpGet(url)
+func getClusterRoutingAllocation(pool *endpointPool) (string, error) {
+	esData, err := httpGet(pool, "/_cluster/settings")
 	if err != nil {
 		return "", err
 	}
@@ -184,38 +228,6 @@ func getClusterRoutingAllocation(esUrl string) (string, error) {
 	return clusterRoutingAllocation.Transient.Cluster.Routing.Allocation.Enable, nil
 }
 
-func getInvalidNodes(localNodes map[string]map[string]interface{}, activeNodes map[string]struct{}) []string {
-	var nodesToRestart []string
-	for service, value := range localNodes {
-		if _, ok := activeNodes[value["instance"].(string)]; !ok {
-			nodesToRestart = append(nodesToRestart, service)
-		}
-	}
-
-	return nodesToRestart
-}
-
-func restartSystemdService(service string) error {
-	_, err := executeCommand("systemctl", []string{"restart", service})
-	if err != nil {
-		return fmt.Errorf("Command execution fail: %v", err)
-	}
-
-	return nil
-}
-
-func localNodesToMap(localNodes LocalNodes) map[string]map[string]interface{} {
-	nodes := make(map[string]map[string]interface{})
-	for _, localNode := range localNodes.Nodes {
-		nodes[localNode.Service] = map[string]interface{}{
-			"instance":             localNode.Instance,
-			"lastRestartTimestamp": 0,
-		}
-	}
-
-	return nodes
-}
-
 func executeCommand(command string, args []string) (string, error) {
 	cmd := exec.Command(command, args...)
 	slog.Debug("Executing", "command", fmt.Sprintf("%v %v", command, strings.Join(args, " ")))
@@ -232,101 +244,6 @@ func executeCommand(command string, args []string) (string, error) {
 	return stdout.String(), nil
 }
 
-func getSystemdServiceActiveEnterTimestamp(service string) (int, error) {
-	stdout, err := executeCommand("systemctl", []string{"--no-pager", "--property=ActiveEnterTimestamp", "show", service})
-	if err != nil {
-		return 0, fmt.Errorf("Command execution fail: %v", err)
-	}
-
-	r := regexp.MustCompile(`ActiveEnterTimestamp=([ a-zA-Z0-9:-]+)`)
-	findStrResult := r.FindStringSubmatch(stdout)
-	if len(findStrResult) < 2 {
-		return 0, fmt.Errorf("Cannot find timestamp string in command output")
-	}
-
-	timestamp, err := time.Parse(systemdDateLayout, findStrResult[1])
-	if err != nil {
-		return 0, fmt.Errorf("Parse date failed: %v", err)
-	}
-
-	return int(timestamp.Unix()), nil
-}
-
-func sleepLoop() {
-	time.Sleep(time.Second * time.Duration(interval))
-}
-
-func nodeKeeper(esUrl string, localNodes map[string]map[string]interface{}) {
-	for {
-		activeNodes, err := getActiveNodes(esUrl)
-		if err != nil {
-			slog.Warn("Cannot get active nodes from cluster")
-			sleepLoop()
-			continue
-		}
-
-		invalidNodes := getInvalidNodes(localNodes, activeNodes)
-		if len(invalidNodes) > 0 {
-			for _, service := range invalidNodes {
-				systemdService := fmt.Sprintf("%s.service", service)
-
-				serviceActiveEnterTimestamp, err := getSystemdServiceActiveEnterTimestamp(systemdService)
-				if err == nil {
-					localNodes[service]["lastRestartTimestamp"] = serviceActiveEnterTimestamp
-				} else {
-					slog.Warn("Cannot get systemd service running time", "error", err)
-				}
-
-				now := int(time.Now().Unix())
-				if now-localNodes[service]["lastRestartTimestamp"].(int) > *restartExclusionPeriod {
-					clusterStatus, err := getClusterStatus(esUrl)
-					if err != nil {
-						slog.Warn("Cannot get cluster status")
-						continue
-					}
-
-					clusterRoutingAllocation, err := getClusterRoutingAllocation(esUrl)
-					if err != nil {
-						slog.Warn("Cannot get cluster routing allocation")
-						continue
-					}
-
-					if clusterRoutingAllocation == "" {
-						slog.Warn("Cluster routing allocation is empty")
-						continue
-					}
-
-					if strings.ToLower(clusterStatus) != "red" && strings.ToLower(clusterRoutingAllocation) == "all" {
-						slog.Info("Local node is not an active member of the cluster, restarting service",
-							"node",
-							localNodes[service]["instance"],
-							"service",
-							systemdService,
-						)
-						if *dryRun {
-							slog.Info("Dry run, skipping")
-						} else {
-							if err := restartSystemdService(systemdService); err == nil {
-								slog.Info("Service restarted", "service", systemdService)
-								localNodes[service]["lastRestartTimestamp"] = now
-							} else {
-								slog.Error("Cannot restart service", "service", service, "error", err)
-							}
-						}
-					} else {
-						slog.Debug("Cannot restart service due to cluster conditions", "service", service)
-					}
-				} else {
-					slog.Debug("Cannot restart service because the minimum time between restarts has not been met", "service", service)
-				}
-			}
-		} else {
-			slog.Debug("All local nodes are active members of the cluster")
-		}
-		sleepLoop()
-	}
-}
-
 func main() {
 	var loggingLevel = new(slog.LevelVar)
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: loggingLevel}))
@@ -352,8 +269,49 @@ func main() {
 	}
 
 	slog.Info("Loaded", "config", localNodes)
-	slog.Info("Elasticsearch URL", "url", *esUrl)
 
-	go nodeKeeper(*esUrl, localNodesToMap(localNodes))
+	nodeStates, err := buildNodeStates(localNodes)
+	if err != nil {
+		slog.Error("Cannot build node states from config", "error", err)
+		os.Exit(1)
+	}
+
+	httpClient, err = buildHTTPClient()
+	if err != nil {
+		slog.Error("Cannot build Elasticsearch HTTP client", "error", err)
+		os.Exit(1)
+	}
+
+	seeds := splitSeeds(*esUrls)
+	slog.Info("Elasticsearch URL", "url", seeds)
+
+	pool := newEndpointPool(seeds, endpointCooldown)
+	engine := NewEngine(pool, nodeStates)
+
+	if *metricsAddr != "" {
+		go startMetricsServer(*metricsAddr)
+	}
+
+	if *adminAddr != "" {
+		go startAdminServer(*adminAddr, engine)
+	}
+
+	go engine.Run()
 	select {}
 }
+
+// splitSeeds flattens the --url flag values, which may each be a single
+// endpoint or a comma-separated list, into a single ordered seed list.
+func splitSeeds(urls []string) []string {
+	var seeds []string
+	for _, url := range urls {
+		for _, seed := range strings.Split(url, ",") {
+			seed = strings.TrimSpace(seed)
+			if seed != "" {
+				seeds = append(seeds, seed)
+			}
+		}
+	}
+
+	return seeds
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// readyTolerance is how long a poll is allowed to be stale before /readyz
+// starts failing.
+const readyTolerance = time.Duration(interval) * 3 * time.Second
+
+var knownClusterStatuses = []string{"green", "yellow", "red"}
+var knownRoutingAllocations = []string{"all", "primaries", "new_primaries", "none"}
+
+var (
+	metricActiveNodes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "esnk_active_nodes",
+		Help: "Number of nodes currently reported active by the cluster.",
+	})
+
+	metricLocalNodesTotal = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "esnk_local_nodes_total",
+		Help: "Number of local nodes configured on this host.",
+	})
+
+	metricLocalNodesInvalid = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "esnk_local_nodes_invalid",
+		Help: "Number of configured local nodes currently missing from the cluster.",
+	})
+
+	metricClusterStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "esnk_cluster_status",
+		Help: "Set to 1 for the cluster's last observed health status, 0 for the others.",
+	}, []string{"status"})
+
+	metricClusterRoutingAllocation = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "esnk_cluster_routing_allocation",
+		Help: "Set to 1 for the cluster's last observed routing allocation setting, 0 for the others.",
+	}, []string{"value"})
+
+	metricHTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "esnk_http_requests_total",
+		Help: "Total Elasticsearch HTTP requests made, by result.",
+	}, []string{"result"})
+
+	metricHTTPRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "esnk_http_request_duration_seconds",
+		Help: "Duration of Elasticsearch HTTP requests.",
+	})
+
+	metricServiceRestartsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "esnk_service_restarts_total",
+		Help: "Total service restart attempts, by service and result.",
+	}, []string{"service", "result"})
+
+	metricLastRestartTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "esnk_last_restart_timestamp_seconds",
+		Help: "Unix timestamp of the last known restart, by service.",
+	}, []string{"service"})
+)
+
+// lastSuccessfulPoll holds the unix timestamp of the last tick that managed
+// to fetch the cluster's active nodes, for /readyz.
+var lastSuccessfulPoll atomic.Int64
+
+func recordSuccessfulPoll() {
+	lastSuccessfulPoll.Store(time.Now().Unix())
+}
+
+// setEnumGauge sets value's entry in gv to 1 and every other known entry to
+// 0, so only the current reading shows up as active in the exported gauge.
+func setEnumGauge(gv *prometheus.GaugeVec, known []string, value string) {
+	for _, v := range known {
+		gv.WithLabelValues(v).Set(0)
+	}
+	if value != "" {
+		gv.WithLabelValues(value).Set(1)
+	}
+}
+
+// startMetricsServer serves Prometheus metrics plus /healthz and /readyz on
+// addr until the process exits.
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		last := lastSuccessfulPoll.Load()
+		if last == 0 || time.Since(time.Unix(last, 0)) > readyTolerance {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	slog.Info("Starting metrics server", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("Metrics server stopped", "error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// httpStatusError carries the HTTP response status code for a failed
+// request, so callers can tell transient failures (5xx, 429) from terminal
+// ones (other 4xx) without re-parsing the error string.
+type httpStatusError struct {
+	statusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP response code: %d", e.statusCode)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return e.statusCode == 429 || e.statusCode >= 500
+}
+
+// attemptTimeout bounds a single HTTP attempt. It's kept well under
+// *timeout so a slow or hanging attempt doesn't consume the entire
+// MaxElapsedTime budget by itself, leaving httpGetURLWithRetry no room to
+// actually retry - the exact "coordinator stops responding" flap this
+// retry wrapper exists to ride out.
+func attemptTimeout() time.Duration {
+	total := time.Second * time.Duration(*timeout)
+	attempt := total / 3
+	if attempt < time.Second {
+		attempt = time.Second
+	}
+
+	return attempt
+}
+
+// httpGetURLWithRetry retries httpGetURL with exponential backoff and
+// jitter on network errors and 5xx/429 responses, giving up once
+// *timeout has elapsed in total. Other 4xx responses are treated as
+// terminal and returned immediately.
+func httpGetURLWithRetry(url string) (string, error) {
+	var body string
+
+	operation := func() error {
+		var err error
+		body, err = httpGetURL(url)
+		if err != nil {
+			var statusErr *httpStatusError
+			if errors.As(err, &statusErr) && !statusErr.retryable() {
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 500 * time.Millisecond
+	b.Multiplier = 1.5
+	b.RandomizationFactor = 0.5
+	b.MaxElapsedTime = time.Second * time.Duration(*timeout)
+
+	if err := backoff.Retry(operation, b); err != nil {
+		return "", err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestHTTPStatusErrorRetryable(t *testing.T) {
+	cases := []struct {
+		statusCode int
+		retryable  bool
+	}{
+		{400, false},
+		{404, false},
+		{429, true},
+		{499, false},
+		{500, true},
+		{502, true},
+		{503, true},
+	}
+
+	for _, c := range cases {
+		err := &httpStatusError{statusCode: c.statusCode}
+		if got := err.retryable(); got != c.retryable {
+			t.Errorf("httpStatusError{%d}.retryable() = %v, want %v", c.statusCode, got, c.retryable)
+		}
+	}
+}
+
+func TestHTTPStatusErrorMessageIncludesStatusCode(t *testing.T) {
+	err := &httpStatusError{statusCode: 503}
+	want := "HTTP response code: 503"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const systemdDateLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// ServiceManager abstracts how a local Elasticsearch instance is restarted
+// and how its last start time is discovered, so the Engine doesn't need to
+// know whether a node runs as a systemd unit, a container, or a pod.
+type ServiceManager interface {
+	Restart(name string) error
+	LastActiveEnter(name string) (time.Time, error)
+}
+
+// newServiceManager builds the ServiceManager configured for a node via the
+// `manager` key in es-node-keeper.yaml. It defaults to systemd, the
+// original and still most common deployment.
+func newServiceManager(kind, namespace, restartCmd, statusCmd string) (ServiceManager, error) {
+	switch kind {
+	case "", "systemd":
+		return &systemdManager{}, nil
+	case "docker":
+		return &dockerManager{}, nil
+	case "kubectl":
+		return newKubectlManager(namespace), nil
+	case "exec":
+		return newExecManager(restartCmd, statusCmd)
+	default:
+		return nil, fmt.Errorf("unknown service manager %q", kind)
+	}
+}
+
+// systemdManager restarts nodes deployed as systemd units.
+type systemdManager struct{}
+
+func (m *systemdManager) unit(name string) string {
+	if strings.HasSuffix(name, ".service") {
+		return name
+	}
+
+	return name + ".service"
+}
+
+func (m *systemdManager) Restart(name string) error {
+	if _, err := executeCommand("systemctl", []string{"restart", m.unit(name)}); err != nil {
+		return fmt.Errorf("command execution failed: %v", err)
+	}
+
+	return nil
+}
+
+func (m *systemdManager) LastActiveEnter(name string) (time.Time, error) {
+	stdout, err := executeCommand("systemctl", []string{"--no-pager", "--property=ActiveEnterTimestamp", "show", m.unit(name)})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("command execution failed: %v", err)
+	}
+
+	r := regexp.MustCompile(`ActiveEnterTimestamp=([ a-zA-Z0-9:-]+)`)
+	findStrResult := r.FindStringSubmatch(stdout)
+	if len(findStrResult) < 2 {
+		return time.Time{}, fmt.Errorf("cannot find timestamp string in command output")
+	}
+
+	timestamp, err := time.Parse(systemdDateLayout, findStrResult[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date failed: %v", err)
+	}
+
+	return timestamp, nil
+}
+
+// dockerManager restarts nodes deployed as docker containers, identified by
+// container name.
+type dockerManager struct{}
+
+func (m *dockerManager) Restart(name string) error {
+	if _, err := executeCommand("docker", []string{"restart", name}); err != nil {
+		return fmt.Errorf("command execution failed: %v", err)
+	}
+
+	return nil
+}
+
+func (m *dockerManager) LastActiveEnter(name string) (time.Time, error) {
+	stdout, err := executeCommand("docker", []string{"inspect", "-f", "{{.State.StartedAt}}", name})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("command execution failed: %v", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(stdout))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date failed: %v", err)
+	}
+
+	return timestamp, nil
+}
+
+// kubectlManager restarts nodes deployed as pods by deleting the pod,
+// relying on its owning controller to recreate it.
+type kubectlManager struct {
+	namespace string
+}
+
+func newKubectlManager(namespace string) *kubectlManager {
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return &kubectlManager{namespace: namespace}
+}
+
+func (m *kubectlManager) Restart(name string) error {
+	if _, err := executeCommand("kubectl", []string{"delete", "pod", name, "-n", m.namespace}); err != nil {
+		return fmt.Errorf("command execution failed: %v", err)
+	}
+
+	return nil
+}
+
+func (m *kubectlManager) LastActiveEnter(name string) (time.Time, error) {
+	stdout, err := executeCommand("kubectl", []string{"get", "pod", name, "-n", m.namespace, "-o", "jsonpath={.status.startTime}"})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("command execution failed: %v", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(stdout))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date failed: %v", err)
+	}
+
+	return timestamp, nil
+}
+
+// execManager restarts and inspects nodes via operator-supplied shell
+// commands, for deployments that don't fit the other backends.
+// statusCmd is expected to print an RFC3339 timestamp on stdout.
+type execManager struct {
+	restartCmd string
+	statusCmd  string
+}
+
+func newExecManager(restartCmd, statusCmd string) (*execManager, error) {
+	if restartCmd == "" || statusCmd == "" {
+		return nil, fmt.Errorf("exec manager requires both restart_cmd and status_cmd")
+	}
+
+	return &execManager{restartCmd: restartCmd, statusCmd: statusCmd}, nil
+}
+
+func (m *execManager) Restart(name string) error {
+	if _, err := executeCommand("sh", []string{"-c", m.restartCmd}); err != nil {
+		return fmt.Errorf("command execution failed: %v", err)
+	}
+
+	return nil
+}
+
+func (m *execManager) LastActiveEnter(name string) (time.Time, error) {
+	stdout, err := executeCommand("sh", []string{"-c", m.statusCmd})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("command execution failed: %v", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(stdout))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date failed: %v", err)
+	}
+
+	return timestamp, nil
+}